@@ -0,0 +1,182 @@
+/*
+Copyright 2014 Andrew Rees.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the
+License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andyrees/loudfixer/pkg/loudness"
+)
+
+// mediaExtensions filters which files directory mode picks up. Glob
+// matches are returned as they are, without this filter.
+var mediaExtensions = map[string]bool{
+	".mp4":  true,
+	".mov":  true,
+	".mkv":  true,
+	".mxf":  true,
+	".avi":  true,
+	".wav":  true,
+	".mp3":  true,
+	".m4a":  true,
+	".flac": true,
+	".aac":  true,
+}
+
+// collectFiles resolves -filename and -list into a concrete list of paths
+// to process. -filename may be a single file, a directory (walked
+// recursively, filtered by mediaExtensions) or a glob pattern.
+func collectFiles(checkFile, list string) ([]string, error) {
+	if list != "" {
+		return readFileList(list)
+	}
+
+	info, err := os.Stat(checkFile)
+	if err == nil && info.IsDir() {
+		var files []string
+		walkErr := filepath.Walk(checkFile, func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !fi.IsDir() && mediaExtensions[strings.ToLower(filepath.Ext(p))] {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if walkErr != nil {
+			return nil, walkErr
+		}
+		return files, nil
+	}
+
+	if strings.ContainsAny(checkFile, "*?[") {
+		return filepath.Glob(checkFile)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return []string{checkFile}, nil
+}
+
+func readFileList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var files []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		files = append(files, line)
+	}
+	return files, scanner.Err()
+}
+
+// runBatch analyzes (and, with -autofix, normalizes) files through a pool
+// of jobs workers, preserving the input order in the returned slice. A
+// file that fails to process is reported on stderr and dropped from the
+// result rather than aborting the batch.
+func runBatch(ctx context.Context, files []string, jobs int) []*loudness.Report {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	reports := make([]*loudness.Report, len(files))
+	indices := make(chan int)
+
+	if isTerminal(os.Stderr) {
+		progressRows = jobs
+		fmt.Fprint(os.Stderr, strings.Repeat("\n", jobs))
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			for i := range indices {
+				report, err := processFile(ctx, files[i], workerID)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s: %v\n", files[i], err)
+					continue
+				}
+				reports[i] = report
+			}
+		}(w)
+	}
+
+	for i := range files {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	if isTerminal(os.Stderr) {
+		fmt.Fprintln(os.Stderr)
+	}
+
+	compacted := reports[:0]
+	for _, r := range reports {
+		if r != nil {
+			compacted = append(compacted, r)
+		}
+	}
+	return compacted
+}
+
+var (
+	progressMu sync.Mutex
+	// progressRows is the number of lines runBatch reserved at the bottom
+	// of the terminal for per-worker progress, one row per worker.
+	progressRows int
+)
+
+// printProgress renders a per-worker progress update parsed from ffmpeg's
+// "-progress pipe:1" output on its own reserved terminal row, so workers
+// don't trample each other's line the way a single shared "\r" would.
+func printProgress(workerID int, file string, p loudness.Progress) {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	outTime := (time.Duration(p.OutTimeUs) * time.Microsecond).Truncate(time.Second)
+	rowsUp := progressRows - workerID
+	fmt.Fprintf(os.Stderr, "\x1b[%dA\r\x1b[2K[worker %d] %-30s frame=%-8d time=%-10s speed=%-6s\x1b[%dB\r",
+		rowsUp, workerID, filepath.Base(file), p.Frame, outTime, p.Speed, rowsUp)
+}
+
+// isTerminal reports whether f is connected to a character device, as a
+// rough stand-in for a TTY check.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
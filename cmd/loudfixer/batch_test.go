@@ -0,0 +1,106 @@
+/*
+Copyright 2014 Andrew Rees.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the
+License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestCollectFilesSingleFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.mov")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := collectFiles(file, "")
+	if err != nil {
+		t.Fatalf("collectFiles returned error: %v", err)
+	}
+	if len(files) != 1 || files[0] != file {
+		t.Errorf("collectFiles() = %v, want [%s]", files, file)
+	}
+}
+
+func TestCollectFilesDirectoryFiltersByExtension(t *testing.T) {
+	dir := t.TempDir()
+	mov := filepath.Join(dir, "a.mov")
+	txt := filepath.Join(dir, "notes.txt")
+	for _, f := range []string{mov, txt} {
+		if err := os.WriteFile(f, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := collectFiles(dir, "")
+	if err != nil {
+		t.Fatalf("collectFiles returned error: %v", err)
+	}
+	if len(files) != 1 || files[0] != mov {
+		t.Errorf("collectFiles() = %v, want [%s]", files, mov)
+	}
+}
+
+func TestCollectFilesGlob(t *testing.T) {
+	dir := t.TempDir()
+	var want []string
+	for _, name := range []string{"a.wav", "b.wav"} {
+		f := filepath.Join(dir, name)
+		if err := os.WriteFile(f, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, f)
+	}
+
+	files, err := collectFiles(filepath.Join(dir, "*.wav"), "")
+	if err != nil {
+		t.Fatalf("collectFiles returned error: %v", err)
+	}
+	sort.Strings(files)
+	sort.Strings(want)
+	if len(files) != len(want) {
+		t.Fatalf("collectFiles() = %v, want %v", files, want)
+	}
+	for i := range files {
+		if files[i] != want[i] {
+			t.Errorf("collectFiles()[%d] = %s, want %s", i, files[i], want[i])
+		}
+	}
+}
+
+func TestCollectFilesList(t *testing.T) {
+	dir := t.TempDir()
+	f1 := filepath.Join(dir, "a.mov")
+	f2 := filepath.Join(dir, "b.mov")
+	listFile := filepath.Join(dir, "list.txt")
+	if err := os.WriteFile(listFile, []byte(f1+"\n\n"+f2+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := collectFiles("", listFile)
+	if err != nil {
+		t.Fatalf("collectFiles returned error: %v", err)
+	}
+	want := []string{f1, f2}
+	if len(files) != len(want) || files[0] != want[0] || files[1] != want[1] {
+		t.Errorf("collectFiles() = %v, want %v", files, want)
+	}
+}
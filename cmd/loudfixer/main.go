@@ -0,0 +1,244 @@
+/*
+Copyright 2014 Andrew Rees.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the
+License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/andyrees/loudfixer/pkg/loudness"
+)
+
+var (
+	checkFile    = flag.String("filename", "", "Full path of file, directory or glob to check")
+	fileList     = flag.String("list", "", "Path to a file containing newline-separated paths to check")
+	jobs         = flag.Int("jobs", runtime.NumCPU(), "Number of files to process concurrently in batch mode")
+	loudnessStd  = flag.Bool("ebu", true, "True for EBUR 128, False for ATSC A/85 RP")
+	autoFix      = flag.Bool("autofix", false, "True to automatically correct the audio levels")
+	outputFormat = flag.String("output", "json", "choose: json | xml | simple | csv")
+	targetI      = flag.Float64("target-i", loudness.EBUR128.I, "Target integrated loudness in LUFS for -autofix normalization")
+	targetTP     = flag.Float64("target-tp", loudness.EBUR128.TP, "Target maximum true peak in dBTP for -autofix normalization")
+	targetLRA    = flag.Float64("target-lra", loudness.EBUR128.LRA, "Target loudness range in LU for -autofix normalization")
+	streamFlag   = flag.String("stream", "0:a:0", "Audio stream(s) to check: 0:a:N | all | comma-separated indices")
+	langFlag     = flag.String("lang", "", "Restrict -stream selection to audio streams tagged with this language, e.g. eng")
+	backendFlag  = flag.String("backend", "exec", "ffmpeg backend to measure with: exec | wasm (requires building with -tags wazero)")
+
+	streamSelector loudness.StreamSelector
+	backend        loudness.Backend
+)
+
+func main() {
+	flag.Parse()
+
+	var err error
+	streamSelector, err = loudness.ParseStreamSelector(*streamFlag, *langFlag)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	backend, err = loudness.NewBackend(*backendFlag)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if *autoFix && *backendFlag != "" && *backendFlag != "exec" {
+		log.Fatalln("-autofix requires the exec backend; the two-pass loudnorm normalize isn't implemented for -backend " + *backendFlag)
+	}
+
+	ctx := context.Background()
+
+	files, err := collectFiles(*checkFile, *fileList)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if len(files) == 1 && *fileList == "" {
+		report, err := processFile(ctx, files[0], -1)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		printReport(report)
+		return
+	}
+
+	reports := runBatch(ctx, files, *jobs)
+	printReports(reports)
+}
+
+func outfileFor(checkFile string) string {
+	fname := path.Base(checkFile)
+	fdir := path.Dir(checkFile)
+	fExt := path.Ext(checkFile)
+	return path.Join(fdir, fmt.Sprintf("%s-fixedAudio%s", strings.Split(fname, ".")[0], fExt))
+}
+
+// processFile analyzes a single file and, if it fails the chosen standard
+// and -autofix is set, normalizes it. workerID is -1 outside batch mode.
+func processFile(ctx context.Context, file string, workerID int) (*loudness.Report, error) {
+	analyzer := loudness.NewAnalyzer(*loudnessStd)
+	analyzer.Streams = streamSelector
+	analyzer.Backend = backend
+
+	report, err := analyzer.Analyze(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+
+	failedIndices := make([]int, 0, len(report.Streams))
+	for _, s := range report.Streams {
+		if !s.PassedOrFailed {
+			failedIndices = append(failedIndices, s.StreamIndex)
+		}
+	}
+
+	if len(failedIndices) > 0 && *autoFix {
+		target := loudness.Target{I: *targetI, TP: *targetTP, LRA: *targetLRA}
+		fixer := &loudness.Fixer{}
+		if workerID >= 0 && isTerminal(os.Stderr) {
+			fixer.OnProgress = func(p loudness.Progress) {
+				printProgress(workerID, file, p)
+			}
+		}
+
+		out := outfileFor(file)
+		all, err := loudness.ProbeAudioStreams(ctx, file)
+		if err != nil {
+			return report, err
+		}
+
+		// A container with more than one audio stream must go through the
+		// stream-aware path even when only one of them failed and was
+		// selected, so passthrough tracks (alt-language dubs, M&E stems)
+		// are mapped untouched instead of all being blown through the
+		// single stream-0 loudnorm filter Normalize applies.
+		if len(all) > 1 {
+			err = fixer.NormalizeStreams(ctx, file, out, target, all, failedIndices)
+		} else {
+			err = fixer.Normalize(ctx, file, out, target)
+		}
+		if err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+func printReport(report *loudness.Report) {
+	switch strings.ToLower(*outputFormat) {
+	case "json":
+		jsonObj, err := json.MarshalIndent(report, "", " ")
+		if err != nil {
+			log.Fatalln(err)
+		}
+		os.Stdout.Write(jsonObj)
+		fmt.Println("")
+	case "xml":
+		xmlObj, err := xml.MarshalIndent(report, "", " ")
+		if err != nil {
+			log.Fatalln(err)
+		}
+		os.Stdout.Write(xmlObj)
+		fmt.Println("")
+	case "simple":
+		fmt.Fprintf(os.Stdout, "%s\nLoudness: %s\nAdjustment: %s\nPassed=%t\n", report.FileName, report.Loudness, report.RecommendedAdjustmentString, report.PassedOrFailed)
+	case "csv":
+		writeSamplesCSV(os.Stdout, []*loudness.Report{report})
+	default:
+		fmt.Println("File checked to loudness standard: ", report.Standard)
+		if report.PassedOrFailed {
+			fmt.Printf("FILE IS COMPLIANT TO STANDARD\n")
+			fmt.Printf("%s\n", report.Standard)
+			fmt.Printf("LOUDNESS: %s LUFS\n", report.Loudness)
+		} else {
+			fmt.Printf("FILE IS NOT COMPLIANT TO STANDARD\n")
+			fmt.Printf("%s \n", report.Standard)
+			fmt.Printf("LOUDNESS: %s LUFS\n", report.Loudness)
+			fmt.Printf("RECOMMENDED ADJUSTMENT: %.1fdB\n", report.RecommendedAdjustment)
+			fmt.Printf("IN ORDER TO ACHIEVE THE MEDIAN VALUE\n")
+		}
+	}
+}
+
+// printReports renders the results of a batch run. json and xml emit the
+// full array in one shot; simple and the default text format print one
+// report at a time, in the order files were queued.
+func printReports(reports []*loudness.Report) {
+	switch strings.ToLower(*outputFormat) {
+	case "json":
+		jsonObj, err := json.MarshalIndent(reports, "", " ")
+		if err != nil {
+			log.Fatalln(err)
+		}
+		os.Stdout.Write(jsonObj)
+		fmt.Println("")
+	case "xml":
+		xmlObj, err := xml.MarshalIndent(struct {
+			Reports []*loudness.Report `xml:"Report"`
+		}{reports}, "", " ")
+		if err != nil {
+			log.Fatalln(err)
+		}
+		os.Stdout.Write(xmlObj)
+		fmt.Println("")
+	case "csv":
+		writeSamplesCSV(os.Stdout, reports)
+	default:
+		for _, report := range reports {
+			if report == nil {
+				continue
+			}
+			printReport(report)
+		}
+	}
+}
+
+// writeSamplesCSV dumps the momentary/short-term/integrated/LRA time
+// series of each report as CSV rows, prefixed with the source file name
+// so multiple reports can share one sheet.
+func writeSamplesCSV(w io.Writer, reports []*loudness.Report) {
+	writer := csv.NewWriter(w)
+	defer writer.Flush()
+
+	writer.Write([]string{"file", "time_seconds", "momentary_lufs", "short_term_lufs", "integrated_lufs", "lra_lu"})
+	for _, report := range reports {
+		if report == nil {
+			continue
+		}
+		for _, s := range report.Samples {
+			writer.Write([]string{
+				report.FileName,
+				strconv.FormatFloat(s.TimeSeconds, 'f', 3, 64),
+				strconv.FormatFloat(s.Momentary, 'f', 1, 64),
+				strconv.FormatFloat(s.ShortTerm, 'f', 1, 64),
+				strconv.FormatFloat(s.Integrated, 'f', 1, 64),
+				strconv.FormatFloat(s.LRA, 'f', 1, 64),
+			})
+		}
+	}
+}
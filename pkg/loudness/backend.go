@@ -0,0 +1,105 @@
+/*
+Copyright 2014 Andrew Rees.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the
+License.
+*/
+
+package loudness
+
+import (
+	"context"
+	"fmt"
+)
+
+// ProbeResult is the subset of ffprobe output a Backend needs to expose:
+// the file's audio streams, in 0:a:N order.
+type ProbeResult struct {
+	AudioStreams []AudioStream
+}
+
+// MeasureOptions parameterizes a Backend's loudness measurement.
+type MeasureOptions struct {
+	// StreamIndex is the audio-relative stream to measure, e.g. 0 for 0:a:0.
+	StreamIndex int
+	// Standard is true for EBU R128, false for ATSC A/85 RP.
+	Standard bool
+}
+
+// Measurement is a Backend's loudness measurement for one audio stream.
+type Measurement struct {
+	Passed       bool
+	Loudness     string
+	Adjustment   float64
+	LRA          float64
+	LRALow       float64
+	LRAHigh      float64
+	TruePeakDBTP []float64
+	MomentaryMax float64
+	ShortTermMax float64
+	Samples      []TimedSample
+}
+
+// Backend is the boundary between loudfixer's measurement/normalization
+// logic and whatever actually decodes media: a shelled-out ffmpeg/ffprobe
+// (execBackend, the default) or an in-process WASM build of ffmpeg
+// (wazeroBackend, built with -tags wazero).
+type Backend interface {
+	Probe(ctx context.Context, path string) (*ProbeResult, error)
+	MeasureLoudness(ctx context.Context, path string, opts MeasureOptions) (*Measurement, error)
+}
+
+// NewBackend returns the Backend selected by name: "exec" (the default,
+// also used when name is empty) or "wasm".
+func NewBackend(name string) (Backend, error) {
+	switch name {
+	case "", "exec":
+		return execBackend{}, nil
+	case "wasm":
+		return newWazeroBackend()
+	default:
+		return nil, fmt.Errorf("loudness: unknown backend %q", name)
+	}
+}
+
+// execBackend shells out to the ffmpeg/ffprobe binaries on PATH. It is
+// the default Backend, used by NewAnalyzer and by an Analyzer whose
+// Backend is nil.
+type execBackend struct{}
+
+func (execBackend) Probe(ctx context.Context, path string) (*ProbeResult, error) {
+	streams, err := probeAudioStreams(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return &ProbeResult{AudioStreams: streams}, nil
+}
+
+func (execBackend) MeasureLoudness(ctx context.Context, path string, opts MeasureOptions) (*Measurement, error) {
+	m, err := measureStream(ctx, path, opts.StreamIndex, opts.Standard)
+	if err != nil {
+		return nil, err
+	}
+	return &Measurement{
+		Passed:       m.passed,
+		Loudness:     m.loudness,
+		Adjustment:   m.adjustment,
+		LRA:          m.lra,
+		LRALow:       m.lraLow,
+		LRAHigh:      m.lraHigh,
+		TruePeakDBTP: m.truePeakDBTP,
+		MomentaryMax: m.momentaryMax,
+		ShortTermMax: m.shortTermMax,
+		Samples:      m.samples,
+	}, nil
+}
@@ -0,0 +1,29 @@
+//go:build !wazero
+
+/*
+Copyright 2014 Andrew Rees.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the
+License.
+*/
+
+package loudness
+
+import "errors"
+
+// newWazeroBackend is stubbed out in binaries built without the "wazero"
+// build tag, so the default build stays a plain exec-only binary with no
+// wazero dependency.
+func newWazeroBackend() (Backend, error) {
+	return nil, errors.New("loudness: wasm backend not compiled in; rebuild with -tags wazero")
+}
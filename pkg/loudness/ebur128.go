@@ -0,0 +1,168 @@
+/*
+Copyright 2014 Andrew Rees.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the
+License.
+*/
+
+package loudness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ebur128Measurement is the parsed result of running ffmpeg's ebur128
+// filter over a single audio stream.
+type ebur128Measurement struct {
+	passed       bool
+	loudness     string
+	adjustment   float64
+	lra          float64
+	lraLow       float64
+	lraHigh      float64
+	truePeakDBTP []float64
+	momentaryMax float64
+	shortTermMax float64
+	samples      []TimedSample
+}
+
+// getFfmpegReadings runs ffmpeg's ebur128 filter, with true-peak
+// reporting enabled, over a single audio stream of filepath and returns
+// its stderr output: a periodic M/S/I/LRA log followed by a final
+// Summary block.
+func getFfmpegReadings(ctx context.Context, filepath string, streamIndex int) (string, error) {
+	binary, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return "", err
+	}
+	filter := fmt.Sprintf("[0:a:%d]ebur128=peak=true", streamIndex)
+	cmd := exec.CommandContext(ctx, binary, "-i", filepath, "-filter_complex", filter, "-f", "null", "-")
+	stdout, err := cmd.StderrPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	out, err := ioutil.ReadAll(stdout)
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Wait(); err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+var (
+	sampleRegexp = regexp.MustCompile(
+		`(?i)t:\s*([\d.]+)\s+TARGET:[^\r\n]*?M:\s*(-?[\d.]+)\s+S:\s*(-?[\d.]+)\s+I:\s*(-?[\d.]+)\s+LUFS\s+LRA:\s*(-?[\d.]+)`,
+	)
+	summaryIRegexp       = regexp.MustCompile(`(?i)I:\s*(-?[\d.]+)\s*LUFS`)
+	summaryLRARegexp     = regexp.MustCompile(`(?i)LRA:\s*(-?[\d.]+)\s*LU\b`)
+	summaryLRALowRegexp  = regexp.MustCompile(`(?i)LRA low:\s*(-?[\d.]+)\s*LUFS`)
+	summaryLRAHighRegexp = regexp.MustCompile(`(?i)LRA high:\s*(-?[\d.]+)\s*LUFS`)
+	summaryPeakRegexp    = regexp.MustCompile(`(?i)Peak:\s*(-?[\d.]+)\s*dBFS`)
+)
+
+// measureStream runs the ebur128 filter over one audio stream of filepath
+// via the exec backend and parses the result.
+func measureStream(ctx context.Context, filepath string, streamIndex int, std bool) (ebur128Measurement, error) {
+	output, err := getFfmpegReadings(ctx, filepath, streamIndex)
+	if err != nil {
+		return ebur128Measurement{}, err
+	}
+	return parseEbur128Output(output, std)
+}
+
+// parseEbur128Output parses ffmpeg ebur128 stderr output - the periodic log
+// into a time series of TimedSamples, and the trailing Summary block into
+// integrated loudness, loudness range and true peak - regardless of which
+// Backend produced it.
+func parseEbur128Output(output string, std bool) (ebur128Measurement, error) {
+	periodic, summary := output, ""
+	if idx := strings.Index(output, "Summary:"); idx != -1 {
+		periodic, summary = output[:idx], output[idx:]
+	}
+
+	m := ebur128Measurement{momentaryMax: math.Inf(-1), shortTermMax: math.Inf(-1)}
+	for _, match := range sampleRegexp.FindAllStringSubmatch(periodic, -1) {
+		sample := TimedSample{
+			TimeSeconds: parseFloat(match[1]),
+			Momentary:   parseFloat(match[2]),
+			ShortTerm:   parseFloat(match[3]),
+			Integrated:  parseFloat(match[4]),
+			LRA:         parseFloat(match[5]),
+		}
+		m.samples = append(m.samples, sample)
+		if sample.Momentary > m.momentaryMax {
+			m.momentaryMax = sample.Momentary
+		}
+		if sample.ShortTerm > m.shortTermMax {
+			m.shortTermMax = sample.ShortTerm
+		}
+	}
+	if len(m.samples) == 0 {
+		m.momentaryMax, m.shortTermMax = 0, 0
+	}
+
+	if match := summaryLRARegexp.FindStringSubmatch(summary); match != nil {
+		m.lra = parseFloat(match[1])
+	}
+	if match := summaryLRALowRegexp.FindStringSubmatch(summary); match != nil {
+		m.lraLow = parseFloat(match[1])
+	}
+	if match := summaryLRAHighRegexp.FindStringSubmatch(summary); match != nil {
+		m.lraHigh = parseFloat(match[1])
+	}
+	for _, match := range summaryPeakRegexp.FindAllStringSubmatch(summary, -1) {
+		m.truePeakDBTP = append(m.truePeakDBTP, parseFloat(match[1]))
+	}
+
+	integrated := summaryIRegexp.FindAllStringSubmatch(summary, -1)
+	if len(integrated) == 0 {
+		return ebur128Measurement{}, errors.New("REGEX PARSE ERROR")
+	}
+	integratedLoudness := integrated[len(integrated)-1][1]
+	integratedLoudnessFloat := parseFloat(integratedLoudness)
+
+	m.loudness = integratedLoudness
+	if std {
+		if integratedLoudnessFloat >= -24 && integratedLoudnessFloat <= -22 {
+			m.passed = true
+		} else {
+			m.adjustment = -23 - integratedLoudnessFloat
+		}
+	} else {
+		if integratedLoudnessFloat >= -26 && integratedLoudnessFloat <= -22 {
+			m.passed = true
+		} else {
+			m.adjustment = -24 - integratedLoudnessFloat
+		}
+	}
+
+	return m, nil
+}
+
+func parseFloat(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2014 Andrew Rees.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the
+License.
+*/
+
+package loudness
+
+import "testing"
+
+const sampleEbur128Output = `[Parsed_ebur128_0 @ 0x0] t: 1.0       TARGET:-23 LUFS    M: -30.1 S: -28.4     I: -26.9 LUFS     LRA:   0.0 LU
+[Parsed_ebur128_0 @ 0x0] t: 2.0       TARGET:-23 LUFS    M: -14.2 S: -15.6     I: -25.3 LUFS     LRA:   2.1 LU
+[Parsed_ebur128_0 @ 0x0] t: 3.0       TARGET:-23 LUFS    M: -18.7 S: -16.0     I: -24.8 LUFS     LRA:   2.8 LU
+[Parsed_ebur128_0 @ 0x0] Summary:
+
+  Integrated loudness:
+    I:         -24.8 LUFS
+    Threshold: -35.2 LUFS
+
+  Loudness range:
+    LRA:         2.8 LU
+    Threshold: -45.2 LUFS
+    LRA low:   -26.1 LUFS
+    LRA high:  -23.3 LUFS
+
+  True peak:
+    Peak:       -3.1 dBFS
+`
+
+func TestParseEbur128OutputMomentaryAndShortTermMax(t *testing.T) {
+	m, err := parseEbur128Output(sampleEbur128Output, true)
+	if err != nil {
+		t.Fatalf("parseEbur128Output returned error: %v", err)
+	}
+
+	// Every sampled Momentary/ShortTerm reading here is negative, so a
+	// zero-valued max would never be overwritten by the scan - the bug
+	// this test guards against.
+	if m.momentaryMax != -14.2 {
+		t.Errorf("momentaryMax = %v, want -14.2", m.momentaryMax)
+	}
+	if m.shortTermMax != -15.6 {
+		t.Errorf("shortTermMax = %v, want -15.6", m.shortTermMax)
+	}
+	if m.lra != 2.8 {
+		t.Errorf("lra = %v, want 2.8", m.lra)
+	}
+	if m.lraLow != -26.1 || m.lraHigh != -23.3 {
+		t.Errorf("lraLow/lraHigh = %v/%v, want -26.1/-23.3", m.lraLow, m.lraHigh)
+	}
+	if len(m.truePeakDBTP) != 1 || m.truePeakDBTP[0] != -3.1 {
+		t.Errorf("truePeakDBTP = %v, want [-3.1]", m.truePeakDBTP)
+	}
+}
+
+func TestParseEbur128OutputNoPeriodicSamples(t *testing.T) {
+	const noSamples = `[Parsed_ebur128_0 @ 0x0] Summary:
+
+  Integrated loudness:
+    I:         -24.8 LUFS
+`
+	m, err := parseEbur128Output(noSamples, true)
+	if err != nil {
+		t.Fatalf("parseEbur128Output returned error: %v", err)
+	}
+	if m.momentaryMax != 0 || m.shortTermMax != 0 {
+		t.Errorf("momentaryMax/shortTermMax = %v/%v, want 0/0 with no samples", m.momentaryMax, m.shortTermMax)
+	}
+}
@@ -0,0 +1,322 @@
+/*
+Copyright 2014 Andrew Rees.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the
+License.
+*/
+
+// Package loudness measures and corrects the EBU R128 / ATSC A/85 loudness
+// of media files via ffmpeg and ffprobe. By default it wraps the ffmpeg
+// binaries directly rather than linking against libav (a wasm Backend,
+// built with -tags wazero, measures in process instead), so callers embedding it in
+// servers or batch pipelines should pass a context that bounds how long a
+// single ffmpeg invocation is allowed to run.
+package loudness
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Target describes the integrated loudness, true peak and loudness range
+// that a normalization pass should converge on. The zero value is not
+// meaningful; use one of the standard presets below or build one from
+// user-supplied values.
+type Target struct {
+	Name string
+	I    float64
+	TP   float64
+	LRA  float64
+}
+
+// Standard loudness presets. I and TP are expressed in LUFS/dBTP, LRA in LU.
+var (
+	EBUR128        = Target{Name: "EBU R128", I: -23, TP: -2, LRA: 7}
+	ATSCA85        = Target{Name: "ATSC A/85", I: -24, TP: -2, LRA: 7}
+	Netflix        = Target{Name: "Netflix", I: -27, TP: -2, LRA: 7}
+	AESStreaming16 = Target{Name: "AES Streaming -16", I: -16, TP: -1, LRA: 7}
+	AESStreaming14 = Target{Name: "AES Streaming -14", I: -14, TP: -1, LRA: 7}
+)
+
+// Report is the result of analyzing a single media file's loudness. The
+// scalar fields describe the primary selected stream (the first one
+// matched by the Analyzer's StreamSelector); Streams carries the full
+// per-stream breakdown for multi-track files.
+type Report struct {
+	FileName                    string
+	PassedOrFailed              bool
+	Loudness                    string
+	RecommendedAdjustment       float64
+	RecommendedAdjustmentString string
+	Standard                    string
+	LRA                         float64
+	TruePeakDBTP                []float64
+	MomentaryMax                float64
+	ShortTermMax                float64
+	Samples                     []TimedSample
+	Streams                     []StreamLoudness
+}
+
+// Analyzer measures the integrated loudness of media files against a
+// chosen standard.
+type Analyzer struct {
+	// Standard is true for EBU R128 (-23 LUFS +/- 1), false for ATSC A/85 RP
+	// (-24 LKFS +/- 2).
+	Standard bool
+
+	// Streams selects which audio stream(s) of a multi-track file to
+	// measure. The zero value measures only the first audio stream.
+	Streams StreamSelector
+
+	// Backend decodes and measures media on the Analyzer's behalf. A nil
+	// Backend measures through the exec backend, which is also what
+	// NewAnalyzer sets. Replace it to measure through an alternative
+	// Backend, e.g. one built with NewBackend("wasm").
+	Backend Backend
+}
+
+// NewAnalyzer returns an Analyzer checking against EBU R128 when ebu is
+// true, or ATSC A/85 RP otherwise. It defaults to the exec backend.
+func NewAnalyzer(ebu bool) *Analyzer {
+	return &Analyzer{Standard: ebu, Backend: execBackend{}}
+}
+
+// Analyze inspects the loudness of the file at path and returns a Report
+// describing whether it passes the Analyzer's standard and, if not, the
+// adjustment in dB required to reach it. Multi-track files are probed via
+// the Analyzer's Backend and narrowed by its StreamSelector; files that the
+// Backend can't enumerate (or that report no audio streams) fall back to a
+// single implicit stream 0, matching loudfixer's historical behavior.
+func (a *Analyzer) Analyze(ctx context.Context, path string) (*Report, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+
+	backend := a.Backend
+	if backend == nil {
+		backend = execBackend{}
+	}
+
+	var audioStreams []AudioStream
+	if probed, err := backend.Probe(ctx, path); err == nil && len(probed.AudioStreams) > 0 {
+		audioStreams = probed.AudioStreams
+	} else {
+		audioStreams = []AudioStream{{Index: 0}}
+	}
+
+	selected := a.Streams.match(audioStreams)
+	if len(selected) == 0 {
+		selected = audioStreams[:1]
+	}
+
+	streams := make([]StreamLoudness, 0, len(selected))
+	for _, s := range selected {
+		m, err := backend.MeasureLoudness(ctx, path, MeasureOptions{StreamIndex: s.Index, Standard: a.Standard})
+		if err != nil {
+			return nil, err
+		}
+		streams = append(streams, StreamLoudness{
+			StreamIndex:                 s.Index,
+			Language:                    s.Language,
+			PassedOrFailed:              m.Passed,
+			Loudness:                    m.Loudness,
+			RecommendedAdjustment:       m.Adjustment,
+			RecommendedAdjustmentString: fmt.Sprintf("%.1fdB", m.Adjustment),
+			LRA:                         m.LRA,
+			LRALow:                      m.LRALow,
+			LRAHigh:                     m.LRAHigh,
+			TruePeakDBTP:                m.TruePeakDBTP,
+			MomentaryMax:                m.MomentaryMax,
+			ShortTermMax:                m.ShortTermMax,
+			Samples:                     m.Samples,
+		})
+	}
+
+	var std string
+	if a.Standard {
+		std = "EBU R128 standard = -23 LUFS +/- 1, True Peak -2dB maximum"
+	} else {
+		std = "ATSC A/85 RP  = -24 LKFS +/- 2, True Peak -2dB maximum"
+	}
+
+	primary := streams[0]
+	return &Report{
+		FileName:                    filepath.Base(path),
+		PassedOrFailed:              primary.PassedOrFailed,
+		Loudness:                    primary.Loudness,
+		RecommendedAdjustment:       primary.RecommendedAdjustment,
+		RecommendedAdjustmentString: primary.RecommendedAdjustmentString,
+		Standard:                    std,
+		LRA:                         primary.LRA,
+		TruePeakDBTP:                primary.TruePeakDBTP,
+		MomentaryMax:                primary.MomentaryMax,
+		ShortTermMax:                primary.ShortTermMax,
+		Samples:                     primary.Samples,
+		Streams:                     streams,
+	}, nil
+}
+
+// loudnormStats mirrors the JSON block ffmpeg's loudnorm filter prints to
+// stderr during the measurement pass. All fields arrive as strings.
+type loudnormStats struct {
+	InputI            string `json:"input_i"`
+	InputTP           string `json:"input_tp"`
+	InputLRA          string `json:"input_lra"`
+	InputThresh       string `json:"input_thresh"`
+	OutputI           string `json:"output_i"`
+	OutputTP          string `json:"output_tp"`
+	OutputLRA         string `json:"output_lra"`
+	OutputThresh      string `json:"output_thresh"`
+	NormalizationType string `json:"normalization_type"`
+	TargetOffset      string `json:"target_offset"`
+}
+
+// parseLoudnormStats extracts and unmarshals the JSON block that ffmpeg
+// writes amongst its regular stderr logging when loudnorm is run with
+// print_format=json.
+func parseLoudnormStats(ffmpegStderr string) (loudnormStats, error) {
+	start := strings.Index(ffmpegStderr, "{")
+	end := strings.LastIndex(ffmpegStderr, "}")
+	if start == -1 || end == -1 || end < start {
+		return loudnormStats{}, errors.New("could not find loudnorm JSON block in ffmpeg output")
+	}
+
+	var stats loudnormStats
+	if err := json.Unmarshal([]byte(ffmpegStderr[start:end+1]), &stats); err != nil {
+		return loudnormStats{}, err
+	}
+	return stats, nil
+}
+
+// Progress reports the state of an in-flight Normalize pass, parsed from
+// ffmpeg's "-progress pipe:1" key=value output.
+type Progress struct {
+	Frame     int64
+	OutTimeUs int64
+	Speed     string
+}
+
+// Fixer normalizes media files to a loudness Target.
+type Fixer struct {
+	// OnProgress, if set, is called with the decoding progress of the
+	// re-encode pass as ffmpeg reports it. It may be called from a
+	// different goroutine than the one that called Normalize.
+	OnProgress func(Progress)
+}
+
+// NewFixer returns a Fixer.
+func NewFixer() *Fixer {
+	return &Fixer{}
+}
+
+// Normalize runs ffmpeg's loudnorm filter in its recommended two-pass mode:
+// a first pass measures the input file's integrated loudness, true peak and
+// loudness range, and a second pass re-encodes using those measured values
+// so the filter can apply linear, true-peak-limited gain rather than
+// guessing from a single pass. The result is written to out.
+func (f *Fixer) Normalize(ctx context.Context, path, out string, target Target) error {
+	binary, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return err
+	}
+
+	analyzeFilter := fmt.Sprintf("loudnorm=I=%.1f:TP=%.1f:LRA=%.1f:print_format=json", target.I, target.TP, target.LRA)
+	measureCmd := exec.CommandContext(ctx, binary, "-i", path, "-af", analyzeFilter, "-f", "null", "-")
+	stderr, err := measureCmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := measureCmd.Start(); err != nil {
+		return err
+	}
+	measureOut, err := ioutil.ReadAll(stderr)
+	if err != nil {
+		return err
+	}
+	if err := measureCmd.Wait(); err != nil {
+		return err
+	}
+
+	measured, err := parseLoudnormStats(string(measureOut))
+	if err != nil {
+		return err
+	}
+
+	applyFilter := fmt.Sprintf(
+		"loudnorm=I=%.1f:TP=%.1f:LRA=%.1f:measured_I=%s:measured_TP=%s:measured_LRA=%s:measured_thresh=%s:offset=%s:linear=true:print_format=summary",
+		target.I, target.TP, target.LRA,
+		measured.InputI, measured.InputTP, measured.InputLRA, measured.InputThresh, measured.TargetOffset,
+	)
+
+	args := []string{
+		"-i", path, "-af", applyFilter,
+		"-map", "0:v?", "-c:v", "copy",
+		"-map", "0:a", "-map", "0:s?",
+	}
+	if f.OnProgress != nil {
+		args = append(args, "-progress", "pipe:1", "-nostats")
+	}
+	args = append(args, "-y", out)
+
+	applyCmd := exec.CommandContext(ctx, binary, args...)
+
+	if f.OnProgress == nil {
+		return applyCmd.Run()
+	}
+
+	progressOut, err := applyCmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := applyCmd.Start(); err != nil {
+		return err
+	}
+	f.readProgress(progressOut)
+	return applyCmd.Wait()
+}
+
+// readProgress scans ffmpeg's "-progress pipe:1" key=value stream and
+// invokes OnProgress once per reported block (each block is terminated by
+// a "progress=continue" or "progress=end" line).
+func (f *Fixer) readProgress(r io.Reader) {
+	block := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		block[key] = value
+		if key == "progress" {
+			f.OnProgress(newProgress(block))
+			block = map[string]string{}
+		}
+	}
+}
+
+func newProgress(block map[string]string) Progress {
+	p := Progress{Speed: block["speed"]}
+	p.Frame, _ = strconv.ParseInt(block["frame"], 10, 64)
+	p.OutTimeUs, _ = strconv.ParseInt(block["out_time_us"], 10, 64)
+	return p
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2014 Andrew Rees.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the
+License.
+*/
+
+package loudness
+
+import "testing"
+
+func TestParseLoudnormStats(t *testing.T) {
+	const stderr = `[Parsed_loudnorm_0 @ 0x0]
+{
+	"input_i" : "-27.61",
+	"input_tp" : "-4.20",
+	"input_lra" : "5.00",
+	"input_thresh" : "-38.03",
+	"output_i" : "-23.02",
+	"output_tp" : "-2.00",
+	"output_lra" : "4.80",
+	"output_thresh" : "-33.44",
+	"normalization_type" : "dynamic",
+	"target_offset" : "0.02"
+}
+`
+	stats, err := parseLoudnormStats(stderr)
+	if err != nil {
+		t.Fatalf("parseLoudnormStats returned error: %v", err)
+	}
+
+	want := loudnormStats{
+		InputI: "-27.61", InputTP: "-4.20", InputLRA: "5.00", InputThresh: "-38.03",
+		OutputI: "-23.02", OutputTP: "-2.00", OutputLRA: "4.80", OutputThresh: "-33.44",
+		NormalizationType: "dynamic", TargetOffset: "0.02",
+	}
+	if stats != want {
+		t.Errorf("parseLoudnormStats() = %+v, want %+v", stats, want)
+	}
+}
+
+func TestParseLoudnormStatsMissingJSON(t *testing.T) {
+	if _, err := parseLoudnormStats("no json block here"); err == nil {
+		t.Fatal("parseLoudnormStats() = nil error, want error for missing JSON block")
+	}
+}
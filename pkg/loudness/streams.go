@@ -0,0 +1,232 @@
+/*
+Copyright 2014 Andrew Rees.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the
+License.
+*/
+
+package loudness
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// AudioStream describes one audio stream of a media file, as enumerated by
+// ffprobe. Index is the audio-relative stream index ffmpeg expects in a
+// "0:a:N" specifier, not the absolute container stream index.
+type AudioStream struct {
+	Index    int
+	Language string
+}
+
+// StreamLoudness is the full EBU R128 measurement set for a single audio
+// stream: integrated loudness plus loudness range, true peak and the
+// momentary/short-term maxima ebur128 reports as it scans the file.
+type StreamLoudness struct {
+	StreamIndex                 int
+	Language                    string
+	PassedOrFailed              bool
+	Loudness                    string
+	RecommendedAdjustment       float64
+	RecommendedAdjustmentString string
+	LRA                         float64
+	LRALow                      float64
+	LRAHigh                     float64
+	TruePeakDBTP                []float64
+	MomentaryMax                float64
+	ShortTermMax                float64
+	Samples                     []TimedSample
+}
+
+// TimedSample is one point of ebur128's periodic loudness log: the
+// momentary (400ms), short-term (3s) and integrated loudness, plus
+// loudness range, as of time TimeSeconds into the stream.
+type TimedSample struct {
+	TimeSeconds float64
+	Momentary   float64
+	ShortTerm   float64
+	Integrated  float64
+	LRA         float64
+}
+
+// StreamSelector picks which audio stream(s) of a multi-track file an
+// Analyzer or Fixer should act on. The zero value selects only the first
+// audio stream (index 0), matching loudfixer's historical single-track
+// behavior.
+type StreamSelector struct {
+	All     bool
+	Indices []int
+	Lang    string
+}
+
+// ParseStreamSelector parses the "-stream" flag value together with an
+// optional "-lang" filter. stream may be "all", a bare stream index such
+// as "1", a comma-separated list of indices, or the ffmpeg-style "0:a:N"
+// form (the "0:a:" prefix is stripped).
+func ParseStreamSelector(stream, lang string) (StreamSelector, error) {
+	sel := StreamSelector{Lang: lang}
+
+	switch {
+	case stream == "" || stream == "0:a:0":
+		return sel, nil
+	case strings.EqualFold(stream, "all"):
+		sel.All = true
+		return sel, nil
+	}
+
+	spec := strings.TrimPrefix(stream, "0:a:")
+	for _, part := range strings.Split(spec, ",") {
+		idx, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return StreamSelector{}, fmt.Errorf("invalid -stream selector %q: %w", stream, err)
+		}
+		sel.Indices = append(sel.Indices, idx)
+	}
+	return sel, nil
+}
+
+// match returns the subset of streams selected, in stream order.
+func (sel StreamSelector) match(streams []AudioStream) []AudioStream {
+	var matched []AudioStream
+	for _, s := range streams {
+		if sel.Lang != "" && !strings.EqualFold(s.Language, sel.Lang) {
+			continue
+		}
+		switch {
+		case sel.All:
+			matched = append(matched, s)
+		case len(sel.Indices) > 0:
+			for _, idx := range sel.Indices {
+				if idx == s.Index {
+					matched = append(matched, s)
+					break
+				}
+			}
+		case sel.Lang != "":
+			matched = append(matched, s)
+		default:
+			if s.Index == 0 {
+				matched = append(matched, s)
+			}
+		}
+	}
+	return matched
+}
+
+type ffprobeStreams struct {
+	Streams []struct {
+		CodecType string `json:"codec_type"`
+		Tags      struct {
+			Language string `json:"language"`
+		} `json:"tags"`
+	} `json:"streams"`
+}
+
+// ProbeAudioStreams enumerates the audio streams of path via ffprobe,
+// returning them in 0:a:N order.
+func ProbeAudioStreams(ctx context.Context, path string) ([]AudioStream, error) {
+	return probeAudioStreams(ctx, path)
+}
+
+func probeAudioStreams(ctx context.Context, path string) ([]AudioStream, error) {
+	ffprobePath, err := exec.LookPath("ffprobe")
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := exec.CommandContext(ctx, ffprobePath,
+		"-show_streams", "-select_streams", "a", "-print_format", "json", path,
+	).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed ffprobeStreams
+	if err := json.Unmarshal(out, &parsed); err != nil {
+		return nil, err
+	}
+
+	streams := make([]AudioStream, len(parsed.Streams))
+	for i, s := range parsed.Streams {
+		streams[i] = AudioStream{Index: i, Language: s.Tags.Language}
+	}
+	return streams, nil
+}
+
+// NormalizeStreams corrects each selected audio stream of path
+// independently, using one loudnorm filter per stream in a single
+// -filter_complex graph, then remuxes the result with the original video
+// and any untouched audio/subtitle streams. Unlike Normalize, this applies
+// a single-pass loudnorm (target values only, no measured_* refinement),
+// since measuring every stream twice is prohibitively slow on files with
+// many tracks. Video and unselected audio are stream-copied; only the
+// normalized audio outputs are re-encoded, each via its own per-output
+// "-c:a:N" specifier so passthrough tracks (other-language dubs, M&E
+// stems) aren't needlessly transcoded.
+func (f *Fixer) NormalizeStreams(ctx context.Context, path, out string, target Target, all []AudioStream, selected []int) error {
+	binary, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return err
+	}
+
+	args := buildNormalizeStreamsArgs(path, out, target, all, selected)
+	return exec.CommandContext(ctx, binary, args...).Run()
+}
+
+// buildNormalizeStreamsArgs builds the ffmpeg argument list for
+// NormalizeStreams: a -filter_complex graph with one loudnorm per selected
+// stream, -map directives preserving stream order, and a per-output -c:a:N
+// specifier so only the normalized outputs are re-encoded.
+func buildNormalizeStreamsArgs(path, out string, target Target, all []AudioStream, selected []int) []string {
+	selectedSet := make(map[int]bool, len(selected))
+	for _, idx := range selected {
+		selectedSet[idx] = true
+	}
+
+	var filterParts []string
+	mapArgs := []string{"-map", "0:v?"}
+	var codecArgs []string
+	audioOutput := 0
+	for _, s := range all {
+		if !selectedSet[s.Index] {
+			mapArgs = append(mapArgs, "-map", fmt.Sprintf("0:a:%d", s.Index))
+			codecArgs = append(codecArgs, fmt.Sprintf("-c:a:%d", audioOutput), "copy")
+			audioOutput++
+			continue
+		}
+		label := fmt.Sprintf("a%d", s.Index)
+		filterParts = append(filterParts, fmt.Sprintf(
+			"[0:a:%d]loudnorm=I=%.1f:TP=%.1f:LRA=%.1f[%s]",
+			s.Index, target.I, target.TP, target.LRA, label,
+		))
+		mapArgs = append(mapArgs, "-map", "["+label+"]")
+		codecArgs = append(codecArgs, fmt.Sprintf("-c:a:%d", audioOutput), "aac")
+		audioOutput++
+	}
+	mapArgs = append(mapArgs, "-map", "0:s?")
+
+	args := []string{"-i", path}
+	if len(filterParts) > 0 {
+		args = append(args, "-filter_complex", strings.Join(filterParts, ";"))
+	}
+	args = append(args, mapArgs...)
+	args = append(args, "-c:v", "copy")
+	args = append(args, codecArgs...)
+	args = append(args, "-c:s", "copy", "-y", out)
+	return args
+}
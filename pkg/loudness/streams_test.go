@@ -0,0 +1,111 @@
+/*
+Copyright 2014 Andrew Rees.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+either express or implied. See the License for the specific
+language governing permissions and limitations under the
+License.
+*/
+
+package loudness
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseStreamSelector(t *testing.T) {
+	cases := []struct {
+		name    string
+		stream  string
+		lang    string
+		want    StreamSelector
+		wantErr bool
+	}{
+		{name: "default", stream: "", want: StreamSelector{}},
+		{name: "explicit first stream", stream: "0:a:0", want: StreamSelector{}},
+		{name: "all", stream: "all", want: StreamSelector{All: true}},
+		{name: "all case insensitive", stream: "ALL", want: StreamSelector{All: true}},
+		{name: "bare index", stream: "1", want: StreamSelector{Indices: []int{1}}},
+		{name: "ffmpeg form", stream: "0:a:2", want: StreamSelector{Indices: []int{2}}},
+		{name: "comma separated", stream: "0,2", want: StreamSelector{Indices: []int{0, 2}}},
+		{name: "with language", stream: "all", lang: "eng", want: StreamSelector{All: true, Lang: "eng"}},
+		{name: "invalid index", stream: "0:a:x", wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ParseStreamSelector(c.stream, c.lang)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("ParseStreamSelector(%q, %q) = nil error, want error", c.stream, c.lang)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseStreamSelector(%q, %q) returned error: %v", c.stream, c.lang, err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("ParseStreamSelector(%q, %q) = %+v, want %+v", c.stream, c.lang, got, c.want)
+			}
+		})
+	}
+}
+
+func TestBuildNormalizeStreamsArgsPreservesPassthroughCodec(t *testing.T) {
+	target := Target{I: -23, TP: -2, LRA: 7}
+	all := []AudioStream{{Index: 0, Language: "eng"}, {Index: 1, Language: "fra"}}
+	args := buildNormalizeStreamsArgs("in.mov", "out.mov", target, all, []int{0})
+
+	want := []string{
+		"-i", "in.mov",
+		"-filter_complex", "[0:a:0]loudnorm=I=-23.0:TP=-2.0:LRA=7.0[a0]",
+		"-map", "0:v?", "-map", "[a0]", "-map", "0:a:1", "-map", "0:s?",
+		"-c:v", "copy", "-c:a:0", "aac", "-c:a:1", "copy",
+		"-c:s", "copy", "-y", "out.mov",
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Fatalf("buildNormalizeStreamsArgs() = %v, want %v", args, want)
+	}
+}
+
+func TestStreamSelectorMatch(t *testing.T) {
+	streams := []AudioStream{
+		{Index: 0, Language: "eng"},
+		{Index: 1, Language: "fra"},
+		{Index: 2, Language: "eng"},
+	}
+
+	cases := []struct {
+		name string
+		sel  StreamSelector
+		want []int
+	}{
+		{name: "zero value picks first stream", sel: StreamSelector{}, want: []int{0}},
+		{name: "all", sel: StreamSelector{All: true}, want: []int{0, 1, 2}},
+		{name: "indices", sel: StreamSelector{Indices: []int{1, 2}}, want: []int{1, 2}},
+		{name: "language filter", sel: StreamSelector{Lang: "eng"}, want: []int{0, 2}},
+		{name: "language narrows all", sel: StreamSelector{All: true, Lang: "fra"}, want: []int{1}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			matched := c.sel.match(streams)
+			got := make([]int, len(matched))
+			for i, s := range matched {
+				got[i] = s.Index
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("match() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}